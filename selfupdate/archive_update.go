@@ -3,8 +3,11 @@ package selfupdate
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -16,18 +19,19 @@ import (
 )
 
 func (u *Updater) updateFromArchive(srcExec string) error {
-	archiveData, err := u.downloadArchive()
-	if err != nil {
-		return fmt.Errorf("failed to download archive: %w", err)
-	}
-
 	tempDir, err := ioutil.TempDir("", "selfupdate")
 	if err != nil {
 		return fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
 
-	err = u.extractArchive(archiveData, tempDir)
+	archiveFile, err := u.downloadArchiveToFile(tempDir)
+	if err != nil {
+		return fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer archiveFile.Close()
+
+	err = u.extractArchive(archiveFile, tempDir)
 	if err != nil {
 		return fmt.Errorf("failed to extract archive: %w", err)
 	}
@@ -55,6 +59,72 @@ func (u *Updater) downloadArchive() (io.ReadCloser, error) {
 		return nil, fmt.Errorf("failed to construct download URL: %w", err)
 	}
 
+	return u.fetch(urlLink)
+}
+
+func (u *Updater) downloadArchiveToFile(tempDir string) (*os.File, error) {
+	archiveStream, err := u.downloadArchive()
+	if err != nil {
+		return nil, err
+	}
+	defer archiveStream.Close()
+
+	archiveFile, err := os.Create(filepath.Join(tempDir, "archive.download"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download file: %w", err)
+	}
+
+	hasher := sha256.New()
+	counted := &countingReader{
+		r:          io.TeeReader(archiveStream, hasher),
+		maxBytes:   u.MaxArchiveBytes,
+		total:      -1, // Requester has no way to report Content-Length
+		progressFn: u.ProgressFn,
+	}
+
+	if _, err := io.Copy(archiveFile, counted); err != nil {
+		archiveFile.Close()
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if err := u.verifyChecksum(hex.EncodeToString(hasher.Sum(nil))); err != nil {
+		archiveFile.Close()
+		return nil, err
+	}
+
+	if _, err := archiveFile.Seek(0, io.SeekStart); err != nil {
+		archiveFile.Close()
+		return nil, fmt.Errorf("failed to rewind downloaded archive: %w", err)
+	}
+
+	return archiveFile, nil
+}
+
+// countingReader enforces maxBytes and reports progress as r is read.
+type countingReader struct {
+	r          io.Reader
+	maxBytes   int64
+	total      int64
+	progressFn func(bytesRead, totalBytes int64)
+	read       int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+
+	if c.maxBytes > 0 && c.read > c.maxBytes {
+		return n, fmt.Errorf("archive exceeds maximum allowed size (%d bytes)", c.maxBytes)
+	}
+
+	if n > 0 && c.progressFn != nil {
+		c.progressFn(c.read, c.total)
+	}
+
+	return n, err
+}
+
+func (u *Updater) fetch(urlLink string) (io.ReadCloser, error) {
 	if u.Requester == nil {
 		return defaultHTTPRequester.Fetch(urlLink)
 	}
@@ -68,7 +138,24 @@ func (u *Updater) downloadArchive() (io.ReadCloser, error) {
 	return r, nil
 }
 
+// extractArchive sniffs data's format and unpacks it into destDir, falling
+// back to ArchiveFormat (or the OS default) if nothing matches.
 func (u *Updater) extractArchive(data io.ReadCloser, destDir string) error {
+	header, r, err := peekHeader(data)
+	if err != nil {
+		return fmt.Errorf("failed to read archive data: %w", err)
+	}
+
+	if archiver := findArchiver(header); archiver != nil {
+		if sc, ok := archiver.(stripComponentsAware); ok {
+			archiver = sc.withStripComponents(u.StripComponents)
+		}
+		if mb, ok := archiver.(maxBytesAware); ok {
+			archiver = mb.withMaxBytes(u.MaxArchiveBytes)
+		}
+		return archiver.Extract(r, destDir)
+	}
+
 	archiveFormat := u.ArchiveFormat
 	if archiveFormat == "" {
 		if runtime.GOOS == "windows" {
@@ -78,31 +165,103 @@ func (u *Updater) extractArchive(data io.ReadCloser, destDir string) error {
 		}
 	}
 
-	archiveData, err := io.ReadAll(data)
-	if err != nil {
-		return fmt.Errorf("failed to read archive data: %w", err)
-	}
-
 	switch archiveFormat {
 	case "zip":
-		return u.extractZip(archiveData, destDir)
+		return extractZipReader(r, destDir, u.StripComponents, u.MaxArchiveBytes)
 	case "tar.gz":
-		return u.extractTarGz(archiveData, destDir)
+		gzReader, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip archive: %w", err)
+		}
+		defer gzReader.Close()
+
+		return extractTarReader(gzReader, destDir, u.StripComponents, u.MaxArchiveBytes)
 	default:
 		return fmt.Errorf("unsupported archive format: %s", archiveFormat)
 	}
 }
 
-func (u *Updater) extractZip(data []byte, destDir string) error {
+// peekHeader returns data's leading sniffLen bytes without consuming them.
+func peekHeader(data io.ReadCloser) ([]byte, io.Reader, error) {
+	if seeker, ok := data.(io.Seeker); ok {
+		buf := make([]byte, sniffLen)
+		n, err := io.ReadFull(data, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, nil, err
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		return buf[:n], data, nil
+	}
+
+	br := bufio.NewReaderSize(data, sniffLen)
+	header, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+	return header, br, nil
+}
+
+func extractZipReader(r io.Reader, destDir string, stripComponents int, maxBytes int64) error {
+	if ra, size, ok := readerAtWithSize(r); ok {
+		zipReader, err := zip.NewReader(ra, size)
+		if err != nil {
+			return err
+		}
+		return extractZipEntries(zipReader, destDir, stripComponents, maxBytes)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	return extractZipData(data, destDir, stripComponents, maxBytes)
+}
+
+func readerAtWithSize(r io.Reader) (io.ReaderAt, int64, bool) {
+	switch v := r.(type) {
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil {
+			return nil, 0, false
+		}
+		return v, info.Size(), true
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return v, v.Size(), true
+	default:
+		return nil, 0, false
+	}
+}
+
+func extractZipData(data []byte, destDir string, stripComponents int, maxBytes int64) error {
 	reader := bytes.NewReader(data)
 	zipReader, err := zip.NewReader(reader, int64(len(data)))
 	if err != nil {
 		return err
 	}
 
+	return extractZipEntries(zipReader, destDir, stripComponents, maxBytes)
+}
+
+// extractZipEntries rejects the archive outright if the sum of its entries'
+// declared sizes exceeds maxBytes, before extracting any of them.
+func extractZipEntries(zipReader *zip.Reader, destDir string, stripComponents int, maxBytes int64) error {
+	if maxBytes > 0 {
+		var total uint64
+		for _, file := range zipReader.File {
+			total += file.UncompressedSize64
+			if total > uint64(maxBytes) {
+				return fmt.Errorf("archive exceeds maximum allowed size (%d bytes)", maxBytes)
+			}
+		}
+	}
+
 	for _, file := range zipReader.File {
-		err := u.extractZipFile(file, destDir)
-		if err != nil {
+		if err := extractZipFile(file, destDir, stripComponents); err != nil {
 			return err
 		}
 	}
@@ -110,47 +269,84 @@ func (u *Updater) extractZip(data []byte, destDir string) error {
 	return nil
 }
 
-func (u *Updater) extractZipFile(file *zip.File, destDir string) error {
-	rc, err := file.Open()
+func extractZipFile(file *zip.File, destDir string, stripComponents int) error {
+	name, ok := stripPathComponents(file.Name, stripComponents)
+	if !ok {
+		return nil
+	}
+
+	path, err := safeJoin(destDir, name)
 	if err != nil {
 		return err
 	}
-	defer rc.Close()
 
-	path := filepath.Join(destDir, file.Name)
+	mode := file.FileInfo().Mode()
 
-	// Security check
-	if !strings.HasPrefix(path, filepath.Clean(destDir)+string(os.PathSeparator)) {
-		return fmt.Errorf("invalid file path: %s", file.Name)
+	// zip has no native symlink type; Unix-created archives instead
+	// record it as a regular entry whose external attributes carry the
+	// S_IFLNK bit, with the link target as the entry's content.
+	if mode&os.ModeSymlink != 0 {
+		return extractZipSymlink(file, path, destDir)
 	}
 
 	if file.FileInfo().IsDir() {
-		return os.MkdirAll(path, file.FileInfo().Mode())
+		return os.MkdirAll(path, mode)
 	}
 
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 
-	outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.FileInfo().Mode())
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
 	if err != nil {
 		return err
 	}
 	defer outFile.Close()
 
-	_, err = io.Copy(outFile, rc)
-	return err
+	if _, err := io.Copy(outFile, rc); err != nil {
+		return err
+	}
+
+	os.Chtimes(path, file.Modified, file.Modified)
+	return nil
 }
 
-func (u *Updater) extractTarGz(data []byte, destDir string) error {
-	gzReader, err := gzip.NewReader(bytes.NewReader(data))
+func extractZipSymlink(file *zip.File, path, destDir string) error {
+	rc, err := file.Open()
 	if err != nil {
 		return err
 	}
-	defer gzReader.Close()
+	defer rc.Close()
 
-	tarReader := tar.NewReader(gzReader)
+	target, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
 
+	if _, err := resolveSymlinkTarget(path, string(target), destDir); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	os.Remove(path)
+	return os.Symlink(string(target), path)
+}
+
+// extractTarReader extracts the (already decompressed) tar stream read from
+// r into destDir, shared by every tar-based Archiver.
+func extractTarReader(r io.Reader, destDir string, stripComponents int, maxBytes int64) error {
+	tarReader := tar.NewReader(r)
+
+	var total int64
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
@@ -160,24 +356,50 @@ func (u *Updater) extractTarGz(data []byte, destDir string) error {
 			return err
 		}
 
-		path := filepath.Join(destDir, header.Name)
+		if maxBytes > 0 {
+			total += header.Size
+			if total > maxBytes {
+				return fmt.Errorf("archive exceeds maximum allowed size (%d bytes)", maxBytes)
+			}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeXHeader, tar.TypeXGlobalHeader:
+			// archive/tar already folds PAX extended headers (long
+			// names included) into the entry that follows them; these
+			// only surface here for a handful of non-compliant writers,
+			// and carry no file of their own to extract.
+			continue
+		}
+
+		name, ok := stripPathComponents(header.Name, stripComponents)
+		if !ok {
+			continue
+		}
 
-		// Security check
-		if !strings.HasPrefix(path, filepath.Clean(destDir)+string(os.PathSeparator)) {
-			return fmt.Errorf("invalid file path: %s", header.Name)
+		path, err := safeJoin(destDir, name)
+		if err != nil {
+			return err
 		}
 
+		// Strip setuid/setgid/sticky bits: an untrusted archive has no
+		// business asking the extractor to grant them.
+		mode := os.FileMode(header.Mode) & 0o777
+
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(path, os.FileMode(header.Mode)); err != nil {
+			if err := os.MkdirAll(path, mode); err != nil {
 				return err
 			}
+			os.Chtimes(path, header.ModTime, header.ModTime)
+			chownIfRoot(path, header.Uid, header.Gid)
+
 		case tar.TypeReg:
 			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 				return err
 			}
 
-			outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode))
+			outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
 			if err != nil {
 				return err
 			}
@@ -187,12 +409,103 @@ func (u *Updater) extractTarGz(data []byte, destDir string) error {
 			if err != nil {
 				return err
 			}
+
+			os.Chtimes(path, header.ModTime, header.ModTime)
+			chownIfRoot(path, header.Uid, header.Gid)
+
+		case tar.TypeSymlink:
+			if _, err := resolveSymlinkTarget(path, header.Linkname, destDir); err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+
+			os.Remove(path)
+			if err := os.Symlink(header.Linkname, path); err != nil {
+				return err
+			}
+
+		case tar.TypeLink:
+			linkName, ok := stripPathComponents(header.Linkname, stripComponents)
+			if !ok {
+				continue
+			}
+
+			linkTarget, err := safeJoin(destDir, linkName)
+			if err != nil {
+				return fmt.Errorf("invalid hardlink target: %s", header.Linkname)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+
+			os.Remove(path)
+			if err := os.Link(linkTarget, path); err != nil {
+				return err
+			}
+
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			// Device and FIFO entries aren't meaningful for release
+			// binaries and would need root to create; skip them
+			// rather than failing the whole extraction.
 		}
 	}
 
 	return nil
 }
 
+// safeJoin joins destDir and name, rejecting a result that escapes destDir.
+func safeJoin(destDir, name string) (string, error) {
+	path := filepath.Join(destDir, name)
+	cleanDest := filepath.Clean(destDir)
+
+	if path != cleanDest && !strings.HasPrefix(path, cleanDest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid file path: %s", name)
+	}
+
+	return path, nil
+}
+
+// resolveSymlinkTarget rejects a symlink target that would escape destDir.
+func resolveSymlinkTarget(linkPath, linkName, destDir string) (string, error) {
+	resolved := linkName
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(filepath.Dir(linkPath), resolved)
+	}
+	resolved = filepath.Clean(resolved)
+
+	cleanDest := filepath.Clean(destDir)
+	if resolved != cleanDest && !strings.HasPrefix(resolved, cleanDest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("symlink target escapes destination directory: %s -> %s", linkPath, linkName)
+	}
+
+	return resolved, nil
+}
+
+func stripPathComponents(name string, n int) (string, bool) {
+	if n <= 0 {
+		return name, true
+	}
+
+	parts := strings.Split(filepath.ToSlash(name), "/")
+	if len(parts) <= n {
+		return "", false
+	}
+
+	return filepath.Join(parts[n:]...), true
+}
+
+// chownIfRoot applies uid/gid to path when running as root on a Unix-like OS.
+func chownIfRoot(path string, uid, gid int) {
+	if runtime.GOOS == "windows" || os.Geteuid() != 0 {
+		return
+	}
+	os.Chown(path, uid, gid)
+}
+
 func (u *Updater) validateExtractedFiles(tempDir string) error {
 	exeName := plat
 	if runtime.GOOS == "windows" {
@@ -215,6 +528,9 @@ func (u *Updater) validateExtractedFiles(tempDir string) error {
 	return nil
 }
 
+// replaceFiles swaps the binary and any ExtraFiles into place as a single
+// crash-safe transaction, journaled so RecoverInterruptedUpdate can finish
+// or discard it if interrupted.
 func (u *Updater) replaceFiles(tempDir string, srcExec string) error {
 	currentDir := filepath.Dir(srcExec)
 
@@ -223,48 +539,75 @@ func (u *Updater) replaceFiles(tempDir string, srcExec string) error {
 		exeName += ".exe"
 	}
 
-	//replace BIN
-	newBinPath := filepath.Join(tempDir, u.unpackedArchiveName(), exeName)
-	err := u.replaceFile(newBinPath, srcExec)
-	if err != nil {
-		return fmt.Errorf("cannot replaceFileFromStream. Err: %s, File: %s", err.Error(), newBinPath)
+	pending := []struct{ src, dst string }{
+		{src: filepath.Join(tempDir, u.unpackedArchiveName(), exeName), dst: srcExec},
 	}
-
-	// Add extra files
-	var replacements []struct{ src, dst string }
-
 	for _, extraFile := range u.ExtraFiles {
-		src := filepath.Join(tempDir, u.unpackedArchiveName(), extraFile)
-		dst := filepath.Join(currentDir, extraFile)
-
-		replacements = append(replacements, struct{ src, dst string }{
-			src: src,
-			dst: dst,
+		pending = append(pending, struct{ src, dst string }{
+			src: filepath.Join(tempDir, u.unpackedArchiveName(), extraFile),
+			dst: filepath.Join(currentDir, extraFile),
 		})
 	}
 
-	backups := make(map[string]string)
-	for _, repl := range replacements {
-		if _, err := os.Stat(repl.dst); err == nil {
-			backupPath := repl.dst + ".backup"
-			err := u.copyFile(repl.dst, backupPath)
-			if err != nil {
-				u.restoreBackups(backups)
-				return fmt.Errorf("failed to create backup for %s: %w", repl.dst, err)
+	txnID, err := newTxnID()
+	if err != nil {
+		return fmt.Errorf("failed to start update transaction: %w", err)
+	}
+	journal := &updateJournal{TxnID: txnID, Stage: journalStageStaged}
+
+	for _, p := range pending {
+		staged, err := stageFile(p.src, p.dst)
+		if err != nil {
+			discardStaged(journal)
+			removeBackups(journal)
+			return fmt.Errorf("failed to stage %s: %w", p.dst, err)
+		}
+		journal.Originals = append(journal.Originals, p.dst)
+		journal.Staged = append(journal.Staged, staged)
+
+		backup := ""
+		if _, err := os.Stat(p.dst); err == nil {
+			backup = p.dst + ".backup"
+			if err := u.copyFile(p.dst, backup); err != nil {
+				discardStaged(journal)
+				removeBackups(journal)
+				return fmt.Errorf("failed to create backup for %s: %w", p.dst, err)
 			}
-			backups[repl.dst] = backupPath
+		} else if !os.IsNotExist(err) {
+			discardStaged(journal)
+			removeBackups(journal)
+			return fmt.Errorf("failed to stat %s: %w", p.dst, err)
 		}
+		journal.Backups = append(journal.Backups, backup)
 	}
 
-	for _, repl := range replacements {
-		err := u.replaceFile(repl.src, repl.dst)
-		if err != nil {
-			u.restoreBackups(backups)
-			return fmt.Errorf("failed to replace %s: %w", repl.dst, err)
+	jPath := journalPath(currentDir)
+	if err := writeJournal(jPath, journal); err != nil {
+		discardStaged(journal)
+		removeBackups(journal)
+		return fmt.Errorf("failed to write update journal: %w", err)
+	}
+
+	journal.Stage = journalStageRenaming
+	if err := writeJournal(jPath, journal); err != nil {
+		discardStaged(journal)
+		removeBackups(journal)
+		os.Remove(jPath)
+		return fmt.Errorf("failed to write update journal: %w", err)
+	}
+
+	for i, dst := range journal.Originals {
+		if err := os.Rename(journal.Staged[i], dst); err != nil {
+			restoreRenamed(journal, i)
+			discardStaged(journal)
+			removeBackups(journal)
+			os.Remove(jPath)
+			return fmt.Errorf("failed to replace %s: %w", dst, err)
 		}
 	}
 
-	u.cleanupBackups(backups)
+	removeBackups(journal)
+	os.Remove(jPath)
 
 	return nil
 }
@@ -295,36 +638,6 @@ func (u *Updater) copyFile(src, dst string) error {
 	return os.Chmod(dst, sourceInfo.Mode())
 }
 
-func (u *Updater) replaceFile(src, dst string) error {
-	newBuf, err := os.ReadFile(src)
-	if err != nil {
-		return fmt.Errorf("cannot read file before replace : %s", src)
-	}
-
-	newFileBuffer := bytes.NewBuffer(newBuf)
-
-	err, errRecovery := replaceFileFromStream(newFileBuffer, dst)
-	if errRecovery != nil {
-		return fmt.Errorf("update and recovery errors: %q %q", err, errRecovery)
-	}
-
-	return err
-}
-
-func (u *Updater) restoreBackups(backups map[string]string) {
-	for original, backup := range backups {
-		if _, err := os.Stat(backup); err == nil {
-			os.Rename(backup, original)
-		}
-	}
-}
-
-func (u *Updater) cleanupBackups(backups map[string]string) {
-	for _, backup := range backups {
-		os.Remove(backup)
-	}
-}
-
 func (u *Updater) plat() string {
 	return runtime.GOOS + "-" + runtime.GOARCH
 }
@@ -77,19 +77,28 @@ func TestUpdateFromArchive_ReplaceFilesFailure(t *testing.T) {
 
 	updater := createTestUpdater("tar.gz", mr)
 
-	// Create a temporary directory for the test binary path
+	// Replacement is a rename onto the destination, so a read-only
+	// destination file no longer blocks it; what it does need is a
+	// writable destination directory, since that's where the staged
+	// replacement and its rename target both live.
 	tmpDir := t.TempDir()
-	targetPath := filepath.Join(tmpDir, "myapp")
+	readOnlyDir := filepath.Join(tmpDir, "bin")
+	if err := os.Mkdir(readOnlyDir, 0755); err != nil {
+		t.Fatalf("Failed to create bin dir: %v", err)
+	}
+	targetPath := filepath.Join(readOnlyDir, "myapp")
 
-	// Simulate a file that can't be replaced by making it read-only
-	err := os.WriteFile(targetPath, []byte("dummy"), 0444)
-	if err != nil {
-		t.Fatalf("Failed to create read-only dummy binary: %v", err)
+	if err := os.WriteFile(targetPath, []byte("dummy"), 0755); err != nil {
+		t.Fatalf("Failed to create dummy binary: %v", err)
+	}
+	if err := os.Chmod(readOnlyDir, 0555); err != nil {
+		t.Fatalf("Failed to make bin dir read-only: %v", err)
 	}
+	defer os.Chmod(readOnlyDir, 0755)
 
-	err = updater.updateFromArchive(targetPath)
-	if err == nil || !strings.Contains(err.Error(), "replace") {
-		t.Fatalf("Expected replace error, got: %v", err)
+	err := updater.updateFromArchive(targetPath)
+	if err == nil || !strings.Contains(err.Error(), "stage") {
+		t.Fatalf("Expected stage error, got: %v", err)
 	}
 }
 
@@ -0,0 +1,186 @@
+package selfupdate
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const sniffLen = 512
+
+type Archiver interface {
+	Match(header []byte) bool
+	Extract(r io.Reader, destDir string) error
+}
+
+// stripComponentsAware lets extractArchive pass Updater.StripComponents to
+// the built-in archivers without widening the public Archiver interface.
+type stripComponentsAware interface {
+	withStripComponents(n int) Archiver
+}
+
+// maxBytesAware lets extractArchive pass Updater.MaxArchiveBytes to the
+// built-in archivers the same way it does StripComponents.
+type maxBytesAware interface {
+	withMaxBytes(n int64) Archiver
+}
+
+var archivers []Archiver
+
+// RegisterArchiver prepends a so it takes precedence over the built-ins.
+func RegisterArchiver(a Archiver) {
+	archivers = append([]Archiver{a}, archivers...)
+}
+
+func init() {
+	archivers = []Archiver{
+		gzipArchiver{},
+		zipArchiver{},
+		bzip2Archiver{},
+		xzArchiver{},
+		zstdArchiver{},
+	}
+}
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zipMagic   = []byte{0x50, 0x4b, 0x03, 0x04}
+	bzip2Magic = []byte{0x42, 0x5a, 0x68}
+	xzMagic    = []byte{0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+type gzipArchiver struct {
+	stripComponents int
+	maxBytes        int64
+}
+
+func (gzipArchiver) Match(header []byte) bool { return bytes.HasPrefix(header, gzipMagic) }
+
+func (a gzipArchiver) withStripComponents(n int) Archiver {
+	a.stripComponents = n
+	return a
+}
+
+func (a gzipArchiver) withMaxBytes(n int64) Archiver {
+	a.maxBytes = n
+	return a
+}
+
+func (a gzipArchiver) Extract(r io.Reader, destDir string) error {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip archive: %w", err)
+	}
+	defer gzReader.Close()
+
+	return extractTarReader(gzReader, destDir, a.stripComponents, a.maxBytes)
+}
+
+type zipArchiver struct {
+	stripComponents int
+	maxBytes        int64
+}
+
+func (zipArchiver) Match(header []byte) bool { return bytes.HasPrefix(header, zipMagic) }
+
+func (a zipArchiver) withStripComponents(n int) Archiver {
+	a.stripComponents = n
+	return a
+}
+
+func (a zipArchiver) withMaxBytes(n int64) Archiver {
+	a.maxBytes = n
+	return a
+}
+
+func (a zipArchiver) Extract(r io.Reader, destDir string) error {
+	return extractZipReader(r, destDir, a.stripComponents, a.maxBytes)
+}
+
+type bzip2Archiver struct {
+	stripComponents int
+	maxBytes        int64
+}
+
+func (bzip2Archiver) Match(header []byte) bool { return bytes.HasPrefix(header, bzip2Magic) }
+
+func (a bzip2Archiver) withStripComponents(n int) Archiver {
+	a.stripComponents = n
+	return a
+}
+
+func (a bzip2Archiver) withMaxBytes(n int64) Archiver {
+	a.maxBytes = n
+	return a
+}
+
+func (a bzip2Archiver) Extract(r io.Reader, destDir string) error {
+	return extractTarReader(bzip2.NewReader(r), destDir, a.stripComponents, a.maxBytes)
+}
+
+type xzArchiver struct {
+	stripComponents int
+	maxBytes        int64
+}
+
+func (xzArchiver) Match(header []byte) bool { return bytes.HasPrefix(header, xzMagic) }
+
+func (a xzArchiver) withStripComponents(n int) Archiver {
+	a.stripComponents = n
+	return a
+}
+
+func (a xzArchiver) withMaxBytes(n int64) Archiver {
+	a.maxBytes = n
+	return a
+}
+
+func (a xzArchiver) Extract(r io.Reader, destDir string) error {
+	xzReader, err := xz.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open xz archive: %w", err)
+	}
+	return extractTarReader(xzReader, destDir, a.stripComponents, a.maxBytes)
+}
+
+type zstdArchiver struct {
+	stripComponents int
+	maxBytes        int64
+}
+
+func (zstdArchiver) Match(header []byte) bool { return bytes.HasPrefix(header, zstdMagic) }
+
+func (a zstdArchiver) withStripComponents(n int) Archiver {
+	a.stripComponents = n
+	return a
+}
+
+func (a zstdArchiver) withMaxBytes(n int64) Archiver {
+	a.maxBytes = n
+	return a
+}
+
+func (a zstdArchiver) Extract(r io.Reader, destDir string) error {
+	zstdReader, err := zstd.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd archive: %w", err)
+	}
+	defer zstdReader.Close()
+
+	return extractTarReader(zstdReader, destDir, a.stripComponents, a.maxBytes)
+}
+
+func findArchiver(header []byte) Archiver {
+	for _, a := range archivers {
+		if a.Match(header) {
+			return a
+		}
+	}
+	return nil
+}
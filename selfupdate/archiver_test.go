@@ -0,0 +1,71 @@
+package selfupdate
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindArchiver(t *testing.T) {
+	cases := []struct {
+		name   string
+		header []byte
+		want   Archiver
+	}{
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, gzipArchiver{}},
+		{"zip", []byte{0x50, 0x4b, 0x03, 0x04, 0x14, 0x00}, zipArchiver{}},
+		{"bzip2", []byte{0x42, 0x5a, 0x68, 0x39}, bzip2Archiver{}},
+		{"xz", []byte{0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00, 0x00}, xzArchiver{}},
+		{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x20}, zstdArchiver{}},
+		{"unrecognized", []byte("not an archive"), nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := findArchiver(c.header)
+			if got != c.want {
+				t.Fatalf("findArchiver(%q) = %#v, want %#v", c.header, got, c.want)
+			}
+		})
+	}
+}
+
+// sevenZipArchiver is a throwaway stand-in for a consumer-supplied format,
+// used only to exercise RegisterArchiver.
+type sevenZipArchiver struct{}
+
+var sevenZipMagic = []byte{0x37, 0x7a, 0xbc, 0xaf, 0x27, 0x1c}
+
+func (sevenZipArchiver) Match(header []byte) bool { return bytes.HasPrefix(header, sevenZipMagic) }
+
+func (sevenZipArchiver) Extract(r io.Reader, destDir string) error { return nil }
+
+func TestRegisterArchiver(t *testing.T) {
+	orig := archivers
+	defer func() { archivers = orig }()
+
+	RegisterArchiver(sevenZipArchiver{})
+
+	if got := findArchiver(sevenZipMagic); got != (sevenZipArchiver{}) {
+		t.Fatalf("expected newly registered archiver to be found, got %#v", got)
+	}
+}
+
+func TestExtractArchive_DetectsFormatFromContent(t *testing.T) {
+	data := fakeTarGzArchive(t)
+	tempDir := t.TempDir()
+
+	// Content sniffing should succeed even though ArchiveFormat is left
+	// empty (which would otherwise default to "tar.gz" on this OS, or
+	// "zip" on Windows).
+	updater := &Updater{}
+	if err := updater.extractArchive(io.NopCloser(bytes.NewReader(data)), tempDir); err != nil {
+		t.Fatalf("extractArchive failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "myapp")); err != nil {
+		t.Fatalf("expected extracted file, got: %v", err)
+	}
+}
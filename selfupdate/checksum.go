@@ -0,0 +1,121 @@
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+type ErrChecksumMismatch struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// verifyChecksum is a no-op if neither ExpectedSHA256 nor a checksum source
+// is configured.
+func (u *Updater) verifyChecksum(actual string) error {
+	expected := u.ExpectedSHA256
+	if expected == "" {
+		if u.ChecksumURL == "" && u.ChecksumFn == nil {
+			return nil
+		}
+
+		fetched, err := u.fetchExpectedSHA256(u.archiveName())
+		if err != nil {
+			return fmt.Errorf("failed to fetch checksum: %w", err)
+		}
+		expected = fetched
+	}
+
+	if !strings.EqualFold(expected, actual) {
+		return &ErrChecksumMismatch{Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+func (u *Updater) checksumURL() string {
+	if u.ChecksumFn != nil {
+		return u.ChecksumFn(u)
+	}
+	return u.ChecksumURL
+}
+
+func (u *Updater) fetchExpectedSHA256(name string) (string, error) {
+	checksumURL := u.checksumURL()
+	if checksumURL == "" {
+		return "", fmt.Errorf("no checksum source configured")
+	}
+
+	body, err := u.fetch(checksumURL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	checksumData, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.verifyChecksumSignature(checksumData); err != nil {
+		return "", err
+	}
+
+	return parseSHA256SumLine(checksumData, name)
+}
+
+// verifyChecksumSignature is a no-op unless both PublicKey and SignatureURL
+// are set.
+func (u *Updater) verifyChecksumSignature(checksumData []byte) error {
+	if len(u.PublicKey) == 0 || u.SignatureURL == "" {
+		return nil
+	}
+
+	sigBody, err := u.fetch(u.SignatureURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signature: %w", err)
+	}
+	defer sigBody.Close()
+
+	signature, err := io.ReadAll(sigBody)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	if !ed25519.Verify(u.PublicKey, checksumData, signature) {
+		return fmt.Errorf("checksum file signature verification failed")
+	}
+
+	return nil
+}
+
+// parseSHA256SumLine scans a sha256sum-formatted file for the entry
+// matching name.
+func parseSHA256SumLine(checksumData []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksumData))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		digest, file := fields[0], strings.TrimPrefix(fields[1], "*")
+		if path.Base(file) == name {
+			return digest, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read checksum file: %w", err)
+	}
+
+	return "", fmt.Errorf("no checksum entry found for %s", name)
+}
@@ -0,0 +1,106 @@
+package selfupdate
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseSHA256SumLine(t *testing.T) {
+	data := []byte("deadbeef  otherapp.tar.gz\n" +
+		"0123456789abcdef  myapp-linux-amd64.tar.gz\n")
+
+	digest, err := parseSHA256SumLine(data, "myapp-linux-amd64.tar.gz")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if digest != "0123456789abcdef" {
+		t.Fatalf("got digest %q, want %q", digest, "0123456789abcdef")
+	}
+
+	if _, err := parseSHA256SumLine(data, "missing.tar.gz"); err == nil {
+		t.Fatal("expected error for missing entry")
+	}
+}
+
+func TestVerifyChecksum_NoneConfigured(t *testing.T) {
+	updater := &Updater{}
+	if err := updater.verifyChecksum("anything"); err != nil {
+		t.Fatalf("expected no-op when no checksum source is configured, got: %v", err)
+	}
+}
+
+func TestVerifyChecksum_ExpectedSHA256Match(t *testing.T) {
+	data := []byte("archive contents")
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	updater := &Updater{ExpectedSHA256: digest}
+	if err := updater.verifyChecksum(digest); err != nil {
+		t.Fatalf("expected match, got: %v", err)
+	}
+}
+
+func TestVerifyChecksum_ExpectedSHA256Mismatch(t *testing.T) {
+	updater := &Updater{ExpectedSHA256: "not-the-real-hash"}
+
+	sum := sha256.Sum256([]byte("archive contents"))
+	err := updater.verifyChecksum(hex.EncodeToString(sum[:]))
+	var mismatch *ErrChecksumMismatch
+	if err == nil || !errors.As(err, &mismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got: %v", err)
+	}
+}
+
+func TestVerifyChecksum_FetchedFromChecksumURL(t *testing.T) {
+	data := []byte("archive contents")
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	updater := createTestUpdater("tar.gz", nil)
+	updater.ChecksumURL = "https://example.com/checksums.txt"
+	checksumFile := fmt.Sprintf("%s  %s\n", digest, updater.archiveName())
+
+	mr := &mockRequester{}
+	mr.handleRequest(func(url string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(checksumFile)), nil
+	})
+	updater.Requester = mr
+
+	if err := updater.verifyChecksum(digest); err != nil {
+		t.Fatalf("expected match, got: %v", err)
+	}
+}
+
+func TestVerifyChecksumSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksumData := []byte("0123456789abcdef  myapp-linux-amd64.tar.gz\n")
+	signature := ed25519.Sign(priv, checksumData)
+
+	mr := &mockRequester{}
+	mr.handleRequest(func(url string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(signature)), nil
+	})
+
+	updater := createTestUpdater("tar.gz", mr)
+	updater.PublicKey = pub
+	updater.SignatureURL = "https://example.com/checksums.txt.sig"
+
+	if err := updater.verifyChecksumSignature(checksumData); err != nil {
+		t.Fatalf("expected valid signature, got: %v", err)
+	}
+
+	if err := updater.verifyChecksumSignature([]byte("tampered")); err == nil {
+		t.Fatal("expected signature verification to fail for tampered data")
+	}
+}
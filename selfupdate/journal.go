@@ -0,0 +1,187 @@
+package selfupdate
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const journalFileName = "selfupdate.journal"
+
+// The two stages a transaction passes through: recovery discards staged
+// files up through journalStageStaged, and rolls forward (finishing any
+// remaining renames) from journalStageRenaming onward.
+const (
+	journalStageStaged   = "staged"
+	journalStageRenaming = "renaming"
+)
+
+// updateJournal records an in-progress multi-file replacement. Originals,
+// Staged and Backups are parallel slices: index i of each describes the
+// same file.
+type updateJournal struct {
+	TxnID     string   `json:"txn_id"`
+	Stage     string   `json:"stage"`
+	Originals []string `json:"originals"`
+	Staged    []string `json:"staged"`
+	Backups   []string `json:"backups"` // "" means the original didn't previously exist
+}
+
+func journalPath(dir string) string {
+	return filepath.Join(dir, journalFileName)
+}
+
+func newTxnID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate transaction id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func writeJournal(path string, j *updateJournal) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+func readJournal(path string) (*updateJournal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var j updateJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("corrupt update journal: %w", err)
+	}
+
+	return &j, nil
+}
+
+func stageFile(src, dst string) (string, error) {
+	suffix, err := newTxnID()
+	if err != nil {
+		return "", err
+	}
+	staged := fmt.Sprintf("%s.new-%d-%s", dst, os.Getpid(), suffix)
+
+	sourceInfo, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer sourceFile.Close()
+
+	stagedFile, err := os.OpenFile(staged, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, sourceInfo.Mode())
+	if err != nil {
+		return "", err
+	}
+	defer stagedFile.Close()
+
+	if _, err := io.Copy(stagedFile, sourceFile); err != nil {
+		os.Remove(staged)
+		return "", err
+	}
+
+	if err := stagedFile.Sync(); err != nil {
+		os.Remove(staged)
+		return "", err
+	}
+
+	return staged, nil
+}
+
+func discardStaged(j *updateJournal) {
+	for _, staged := range j.Staged {
+		os.Remove(staged)
+	}
+}
+
+func restoreRenamed(j *updateJournal, count int) {
+	for i := 0; i < count; i++ {
+		if j.Backups[i] == "" {
+			os.Remove(j.Originals[i])
+			continue
+		}
+		os.Rename(j.Backups[i], j.Originals[i])
+	}
+}
+
+func finishRenames(j *updateJournal) error {
+	for i, dst := range j.Originals {
+		if _, err := os.Stat(j.Staged[i]); err != nil {
+			continue // already renamed
+		}
+		if err := os.Rename(j.Staged[i], dst); err != nil {
+			return fmt.Errorf("failed to finish replacing %s: %w", dst, err)
+		}
+	}
+	return nil
+}
+
+func removeBackups(j *updateJournal) {
+	for _, backup := range j.Backups {
+		if backup != "" {
+			os.Remove(backup)
+		}
+	}
+}
+
+// executablePath is overridden in tests so RecoverInterruptedUpdate can be
+// pointed at a journal without replacing the test binary itself.
+var executablePath = os.Executable
+
+// RecoverInterruptedUpdate is safe to call unconditionally at process
+// startup; a no-op if no journal exists.
+func (u *Updater) RecoverInterruptedUpdate() error {
+	exePath, err := executablePath()
+	if err != nil {
+		return fmt.Errorf("failed to locate current executable: %w", err)
+	}
+
+	path := journalPath(filepath.Dir(exePath))
+	j, err := readJournal(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch j.Stage {
+	case journalStageRenaming:
+		// Some renames may already have completed; finish the rest
+		// rather than trying to undo the ones that already landed.
+		if err := finishRenames(j); err != nil {
+			return err
+		}
+	default:
+		// No destination file was touched yet.
+		discardStaged(j)
+	}
+
+	removeBackups(j)
+	return os.Remove(path)
+}
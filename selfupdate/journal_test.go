@@ -0,0 +1,163 @@
+package selfupdate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReplaceFiles_LeavesNoJournalOnSuccess(t *testing.T) {
+	tmpDir := t.TempDir()
+	updater := &Updater{}
+
+	exeName := updater.plat()
+	if runtime.GOOS == "windows" {
+		exeName += ".exe"
+	}
+
+	archiveDir := filepath.Join(tmpDir, updater.unpackedArchiveName())
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(archiveDir, exeName), []byte("new binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	targetPath := filepath.Join(tmpDir, "current", exeName)
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(targetPath, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := updater.replaceFiles(tmpDir, targetPath); err != nil {
+		t.Fatalf("replaceFiles failed: %v", err)
+	}
+
+	got, err := os.ReadFile(targetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "new binary" {
+		t.Fatalf("got %q, want %q", got, "new binary")
+	}
+
+	if _, err := os.Stat(journalPath(filepath.Dir(targetPath))); !os.IsNotExist(err) {
+		t.Fatalf("expected journal to be removed, stat err: %v", err)
+	}
+}
+
+func withExecutablePath(t *testing.T, path string) {
+	t.Helper()
+	orig := executablePath
+	executablePath = func() (string, error) { return path, nil }
+	t.Cleanup(func() { executablePath = orig })
+}
+
+func TestRecoverInterruptedUpdate_RollsForwardPendingRename(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	dst := filepath.Join(tmpDir, "myapp")
+	staged := dst + ".new-1-abc"
+
+	if err := os.WriteFile(dst, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staged, []byte("new binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	j := &updateJournal{
+		TxnID:     "abc",
+		Stage:     journalStageRenaming,
+		Originals: []string{dst},
+		Staged:    []string{staged},
+		Backups:   []string{""},
+	}
+	if err := writeJournal(journalPath(tmpDir), j); err != nil {
+		t.Fatal(err)
+	}
+
+	withExecutablePath(t, filepath.Join(tmpDir, "myapp"))
+
+	updater := &Updater{}
+	if err := updater.RecoverInterruptedUpdate(); err != nil {
+		t.Fatalf("RecoverInterruptedUpdate failed: %v", err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "new binary" {
+		t.Fatalf("expected rename to be finished, got %q", got)
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Fatalf("expected staged file to be consumed by rename, stat err: %v", err)
+	}
+	if _, err := os.Stat(journalPath(tmpDir)); !os.IsNotExist(err) {
+		t.Fatalf("expected journal to be removed, stat err: %v", err)
+	}
+}
+
+func TestRecoverInterruptedUpdate_DiscardsUnstartedTransaction(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	dst := filepath.Join(tmpDir, "myapp")
+	staged := dst + ".new-1-abc"
+	backup := dst + ".backup"
+
+	if err := os.WriteFile(dst, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staged, []byte("new binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(backup, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	j := &updateJournal{
+		TxnID:     "abc",
+		Stage:     journalStageStaged,
+		Originals: []string{dst},
+		Staged:    []string{staged},
+		Backups:   []string{backup},
+	}
+	if err := writeJournal(journalPath(tmpDir), j); err != nil {
+		t.Fatal(err)
+	}
+
+	withExecutablePath(t, filepath.Join(tmpDir, "myapp"))
+
+	updater := &Updater{}
+	if err := updater.RecoverInterruptedUpdate(); err != nil {
+		t.Fatalf("RecoverInterruptedUpdate failed: %v", err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "old binary" {
+		t.Fatalf("expected original to be untouched, got %q", got)
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Fatalf("expected staged file to be discarded, stat err: %v", err)
+	}
+	if _, err := os.Stat(backup); !os.IsNotExist(err) {
+		t.Fatalf("expected backup to be cleaned up, stat err: %v", err)
+	}
+}
+
+func TestRecoverInterruptedUpdate_NoJournalIsNoOp(t *testing.T) {
+	tmpDir := t.TempDir()
+	withExecutablePath(t, filepath.Join(tmpDir, "myapp"))
+
+	updater := &Updater{}
+	if err := updater.RecoverInterruptedUpdate(); err != nil {
+		t.Fatalf("expected no-op, got: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package selfupdate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kr/binarydist"
+)
+
+func makePatch(t *testing.T, oldContent, newContent []byte) []byte {
+	t.Helper()
+
+	var patch bytes.Buffer
+	if err := binarydist.Diff(bytes.NewReader(oldContent), bytes.NewReader(newContent), &patch); err != nil {
+		t.Fatalf("failed to generate patch: %v", err)
+	}
+	return patch.Bytes()
+}
+
+func TestUpdateFromPatch_Success(t *testing.T) {
+	oldContent := []byte(strings.Repeat("old binary content\n", 100))
+	newContent := []byte(strings.Repeat("new binary content\n", 100))
+	patch := makePatch(t, oldContent, newContent)
+
+	sum := sha256.Sum256(newContent)
+	digest := hex.EncodeToString(sum[:])
+
+	mr := &mockRequester{}
+	mr.handleRequest(func(url string) (io.ReadCloser, error) {
+		switch {
+		case strings.HasSuffix(url, ".patch"):
+			return io.NopCloser(bytes.NewReader(patch)), nil
+		case strings.HasSuffix(url, "checksums.txt"):
+			entry := fmt.Sprintf("%s  %s-%s\n", digest, runtime.GOOS, runtime.GOARCH)
+			return io.NopCloser(strings.NewReader(entry)), nil
+		default:
+			return nil, fmt.Errorf("unexpected request: %s", url)
+		}
+	})
+
+	updater := createTestUpdater("tar.gz", mr)
+	updater.PatchMode = true
+	updater.DiffURL = "https://example.com/diffs"
+	updater.ChecksumURL = "https://example.com/checksums.txt"
+	updater.Info.Version = "1.1.0"
+
+	tmpDir := t.TempDir()
+	targetPath := filepath.Join(tmpDir, "myapp")
+	if err := os.WriteFile(targetPath, oldContent, 0755); err != nil {
+		t.Fatalf("failed to create dummy binary: %v", err)
+	}
+
+	if err := updater.updateFromPatch(targetPath); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	got, err := os.ReadFile(targetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, newContent) {
+		t.Fatalf("patched binary does not match expected new content")
+	}
+}
+
+func TestUpdateFromPatch_DisabledFallsBackWithoutFetchingPatch(t *testing.T) {
+	archiveData := fakeTarGzArchive(t)
+
+	mr := &mockRequester{}
+	mr.handleRequest(func(url string) (io.ReadCloser, error) {
+		if strings.HasSuffix(url, ".patch") {
+			t.Fatalf("should not request a patch when PatchMode is disabled: %s", url)
+		}
+		return io.NopCloser(bytes.NewReader(archiveData)), nil
+	})
+
+	updater := createTestUpdater("tar.gz", mr)
+	updater.DiffURL = "https://example.com/diffs"
+	updater.Info.Version = "1.1.0"
+
+	tmpDir := t.TempDir()
+	targetPath := filepath.Join(tmpDir, "myapp")
+	if err := os.WriteFile(targetPath, []byte("old binary"), 0755); err != nil {
+		t.Fatalf("failed to create dummy binary: %v", err)
+	}
+
+	if err := updater.updateFromPatch(targetPath); err != nil {
+		t.Fatalf("expected fallback to updateFromArchive to succeed, got: %v", err)
+	}
+}
+
+func TestUpdateFromPatch_FallsBackWhenPatchMissing(t *testing.T) {
+	archiveData := fakeTarGzArchive(t)
+
+	mr := &mockRequester{}
+	mr.handleRequest(func(url string) (io.ReadCloser, error) {
+		if strings.HasSuffix(url, ".patch") {
+			return nil, fmt.Errorf("404: patch not found")
+		}
+		return io.NopCloser(bytes.NewReader(archiveData)), nil
+	})
+
+	updater := createTestUpdater("tar.gz", mr)
+	updater.PatchMode = true
+	updater.DiffURL = "https://example.com/diffs"
+	updater.Info.Version = "1.1.0"
+
+	tmpDir := t.TempDir()
+	targetPath := filepath.Join(tmpDir, "myapp")
+	if err := os.WriteFile(targetPath, []byte("old binary"), 0755); err != nil {
+		t.Fatalf("failed to create dummy binary: %v", err)
+	}
+
+	if err := updater.updateFromPatch(targetPath); err != nil {
+		t.Fatalf("expected fallback to updateFromArchive to succeed, got: %v", err)
+	}
+}
+
+func TestUpdateFromPatch_ChecksumMismatchFallsBack(t *testing.T) {
+	oldContent := []byte(strings.Repeat("old binary content\n", 100))
+	newContent := []byte(strings.Repeat("new binary content\n", 100))
+	patch := makePatch(t, oldContent, newContent)
+	archiveData := fakeTarGzArchive(t)
+	archiveSum := sha256.Sum256(archiveData)
+	archiveDigest := hex.EncodeToString(archiveSum[:])
+
+	mr := &mockRequester{}
+	mr.handleRequest(func(url string) (io.ReadCloser, error) {
+		switch {
+		case strings.HasSuffix(url, ".patch"):
+			return io.NopCloser(bytes.NewReader(patch)), nil
+		case strings.HasSuffix(url, "checksums.txt"):
+			// The executable's entry is wrong on purpose; the archive's
+			// entry is correct, since the fallback verifies the archive
+			// against the same checksum file.
+			entry := fmt.Sprintf("deadbeef  %s-%s\n%s  %s-%s.tar.gz\n",
+				runtime.GOOS, runtime.GOARCH, archiveDigest, runtime.GOOS, runtime.GOARCH)
+			return io.NopCloser(strings.NewReader(entry)), nil
+		default:
+			return io.NopCloser(bytes.NewReader(archiveData)), nil
+		}
+	})
+
+	updater := createTestUpdater("tar.gz", mr)
+	updater.PatchMode = true
+	updater.DiffURL = "https://example.com/diffs"
+	updater.ChecksumURL = "https://example.com/checksums.txt"
+	updater.Info.Version = "1.1.0"
+
+	tmpDir := t.TempDir()
+	targetPath := filepath.Join(tmpDir, "myapp")
+	if err := os.WriteFile(targetPath, oldContent, 0755); err != nil {
+		t.Fatalf("failed to create dummy binary: %v", err)
+	}
+
+	if err := updater.updateFromPatch(targetPath); err != nil {
+		t.Fatalf("expected fallback to updateFromArchive to succeed, got: %v", err)
+	}
+
+	got, err := os.ReadFile(targetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fallback archive contains "fake-binary" (see fakeTarGzArchive),
+	// not the patched newContent, since the checksum mismatch should have
+	// discarded the patch result before it ever got near replaceFiles.
+	if bytes.Equal(got, newContent) {
+		t.Fatalf("expected patched content to be discarded after checksum mismatch")
+	}
+}
+
+func TestUpdateFromPatch_ExtraFilesFallsBackDirectly(t *testing.T) {
+	archiveData := fakeTarGzArchive(t)
+
+	mr := &mockRequester{}
+	mr.handleRequest(func(url string) (io.ReadCloser, error) {
+		if strings.HasSuffix(url, ".patch") {
+			t.Fatalf("should not request a patch when ExtraFiles is set: %s", url)
+		}
+		return io.NopCloser(bytes.NewReader(archiveData)), nil
+	})
+
+	updater := createTestUpdater("tar.gz", mr)
+	updater.PatchMode = true
+	updater.DiffURL = "https://example.com/diffs"
+	updater.ExtraFiles = []string{"README.md"}
+	updater.Info.Version = "1.1.0"
+
+	tmpDir := t.TempDir()
+	targetPath := filepath.Join(tmpDir, "myapp")
+	if err := os.WriteFile(targetPath, []byte("old binary"), 0755); err != nil {
+		t.Fatalf("failed to create dummy binary: %v", err)
+	}
+
+	err := updater.updateFromPatch(targetPath)
+	if err == nil || !strings.Contains(err.Error(), "required file not found") {
+		t.Fatalf("expected the archive fallback to still validate ExtraFiles, got: %v", err)
+	}
+}
+
+func TestGeneratePatch(t *testing.T) {
+	oldContent := []byte(strings.Repeat("old binary content\n", 100))
+	newContent := []byte(strings.Repeat("new binary content\n", 100))
+
+	tmpDir := t.TempDir()
+	oldPath := filepath.Join(tmpDir, "old")
+	newPath := filepath.Join(tmpDir, "new")
+	patchPath := filepath.Join(tmpDir, "out.patch")
+
+	if err := os.WriteFile(oldPath, oldContent, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, newContent, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := GeneratePatch(oldPath, newPath, patchPath); err != nil {
+		t.Fatalf("GeneratePatch failed: %v", err)
+	}
+
+	patchFile, err := os.Open(patchPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer patchFile.Close()
+
+	var reconstructed bytes.Buffer
+	if err := binarydist.Patch(bytes.NewReader(oldContent), &reconstructed, patchFile); err != nil {
+		t.Fatalf("failed to apply generated patch: %v", err)
+	}
+
+	if !bytes.Equal(reconstructed.Bytes(), newContent) {
+		t.Fatalf("patch did not reconstruct the new binary correctly")
+	}
+}
@@ -0,0 +1,103 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractTarReader_MaxBytesExceeded(t *testing.T) {
+	entries := []*tar.Header{
+		{Name: "myapp", Typeflag: tar.TypeReg, Mode: 0755},
+	}
+	content := bytes.Repeat([]byte("x"), 1024)
+	data := writeTarEntries(t, entries, map[string][]byte{"myapp": content})
+
+	destDir := t.TempDir()
+	err := extractTarReader(bytes.NewReader(data), destDir, 0, 512)
+	if err == nil || !strings.Contains(err.Error(), "exceeds maximum allowed size") {
+		t.Fatalf("expected max size error, got: %v", err)
+	}
+}
+
+func TestExtractTarReader_MaxBytesWithinLimit(t *testing.T) {
+	entries := []*tar.Header{
+		{Name: "myapp", Typeflag: tar.TypeReg, Mode: 0755},
+	}
+	content := []byte("small binary")
+	data := writeTarEntries(t, entries, map[string][]byte{"myapp": content})
+
+	destDir := t.TempDir()
+	if err := extractTarReader(bytes.NewReader(data), destDir, 0, int64(len(content)+1)); err != nil {
+		t.Fatalf("expected extraction within MaxArchiveBytes to succeed, got: %v", err)
+	}
+}
+
+func TestExtractZipReader_MaxBytesExceeded(t *testing.T) {
+	data := fakeZipArchive(t)
+
+	destDir := t.TempDir()
+	err := extractZipReader(bytes.NewReader(data), destDir, 0, 1)
+	if err == nil || !strings.Contains(err.Error(), "exceeds maximum allowed size") {
+		t.Fatalf("expected max size error, got: %v", err)
+	}
+}
+
+func TestExtractZipReader_UsesReaderAtFastPath(t *testing.T) {
+	data := fakeZipArchive(t)
+	destDir := t.TempDir()
+
+	// bytes.Reader implements io.ReaderAt and Size, so extractZipReader
+	// should hand it to zip.NewReader directly rather than buffering it
+	// via io.ReadAll first.
+	if err := extractZipReader(bytes.NewReader(data), destDir, 0, 0); err != nil {
+		t.Fatalf("extractZipReader failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "myapp.exe")); err != nil {
+		t.Fatalf("expected extracted file, got: %v", err)
+	}
+}
+
+func TestReaderAtWithSize(t *testing.T) {
+	if _, _, ok := readerAtWithSize(bytes.NewReader([]byte("data"))); !ok {
+		t.Fatal("expected *bytes.Reader to qualify for the ReaderAt fast path")
+	}
+
+	if _, _, ok := readerAtWithSize(bytes.NewBuffer([]byte("data"))); ok {
+		t.Fatal("expected *bytes.Buffer, which implements neither ReaderAt nor Size, to be rejected")
+	}
+}
+
+func TestCountingReader_EnforcesMaxBytes(t *testing.T) {
+	c := &countingReader{r: bytes.NewReader(bytes.Repeat([]byte("x"), 1024)), maxBytes: 100}
+
+	buf := make([]byte, 1024)
+	_, err := c.Read(buf)
+	if err == nil || !strings.Contains(err.Error(), "exceeds maximum allowed size") {
+		t.Fatalf("expected max size error, got: %v", err)
+	}
+}
+
+func TestCountingReader_ReportsProgress(t *testing.T) {
+	var reported, reportedTotal int64
+	c := &countingReader{
+		r:          bytes.NewReader([]byte("hello world")),
+		total:      11,
+		progressFn: func(bytesRead, totalBytes int64) { reported, reportedTotal = bytesRead, totalBytes },
+	}
+
+	buf := make([]byte, 11)
+	if _, err := c.Read(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reported != 11 {
+		t.Fatalf("got reported %d, want 11", reported)
+	}
+	if reportedTotal != 11 {
+		t.Fatalf("got reported total %d, want 11", reportedTotal)
+	}
+}
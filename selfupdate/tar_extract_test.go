@@ -0,0 +1,127 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func writeTarEntries(t *testing.T, entries []*tar.Header, contents map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range entries {
+		content := contents[hdr.Name]
+		hdr.Size = int64(len(content))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if len(content) > 0 {
+			if _, err := tw.Write(content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractTarReader_Symlink(t *testing.T) {
+	entries := []*tar.Header{
+		{Name: "myapp", Typeflag: tar.TypeReg, Mode: 0755},
+		{Name: "myapp-link", Typeflag: tar.TypeSymlink, Linkname: "myapp", Mode: 0777},
+	}
+	data := writeTarEntries(t, entries, map[string][]byte{"myapp": []byte("binary")})
+
+	destDir := t.TempDir()
+	if err := extractTarReader(bytes.NewReader(data), destDir, 0, 0); err != nil {
+		t.Fatalf("extractTarReader failed: %v", err)
+	}
+
+	target, err := os.Readlink(filepath.Join(destDir, "myapp-link"))
+	if err != nil {
+		t.Fatalf("expected symlink, got: %v", err)
+	}
+	if target != "myapp" {
+		t.Fatalf("got link target %q, want %q", target, "myapp")
+	}
+}
+
+func TestExtractTarReader_SymlinkEscapingDestDirRejected(t *testing.T) {
+	entries := []*tar.Header{
+		{Name: "evil-link", Typeflag: tar.TypeSymlink, Linkname: "../../etc/passwd"},
+	}
+	data := writeTarEntries(t, entries, nil)
+
+	destDir := t.TempDir()
+	if err := extractTarReader(bytes.NewReader(data), destDir, 0, 0); err == nil {
+		t.Fatal("expected symlink escaping destDir to be rejected")
+	}
+}
+
+func TestExtractTarReader_Hardlink(t *testing.T) {
+	entries := []*tar.Header{
+		{Name: "myapp", Typeflag: tar.TypeReg, Mode: 0755},
+		{Name: "myapp-copy", Typeflag: tar.TypeLink, Linkname: "myapp"},
+	}
+	data := writeTarEntries(t, entries, map[string][]byte{"myapp": []byte("binary")})
+
+	destDir := t.TempDir()
+	if err := extractTarReader(bytes.NewReader(data), destDir, 0, 0); err != nil {
+		t.Fatalf("extractTarReader failed: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(destDir, "myapp-copy"))
+	if err != nil {
+		t.Fatalf("expected hardlinked file, got: %v", err)
+	}
+	if string(got) != "binary" {
+		t.Fatalf("got %q, want %q", got, "binary")
+	}
+}
+
+func TestExtractTarReader_StripComponents(t *testing.T) {
+	entries := []*tar.Header{
+		{Name: "myapp-1.2.3/linux-amd64/myapp", Typeflag: tar.TypeReg, Mode: 0755},
+	}
+	data := writeTarEntries(t, entries, map[string][]byte{"myapp-1.2.3/linux-amd64/myapp": []byte("binary")})
+
+	destDir := t.TempDir()
+	if err := extractTarReader(bytes.NewReader(data), destDir, 2, 0); err != nil {
+		t.Fatalf("extractTarReader failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "myapp")); err != nil {
+		t.Fatalf("expected stripped path, got: %v", err)
+	}
+}
+
+func TestExtractTarReader_MasksSetuidBit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permission bits don't apply on windows")
+	}
+
+	entries := []*tar.Header{
+		{Name: "myapp", Typeflag: tar.TypeReg, Mode: 0o4755},
+	}
+	data := writeTarEntries(t, entries, map[string][]byte{"myapp": []byte("binary")})
+
+	destDir := t.TempDir()
+	if err := extractTarReader(bytes.NewReader(data), destDir, 0, 0); err != nil {
+		t.Fatalf("extractTarReader failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(destDir, "myapp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSetuid != 0 {
+		t.Fatalf("expected setuid bit to be stripped, got mode %v", info.Mode())
+	}
+}